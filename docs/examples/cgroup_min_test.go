@@ -0,0 +1,145 @@
+//go:build linux
+
+package examples
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/link"
+)
+
+// newCgroupProgram builds a trivial CGroupSKB program that always allows the
+// packet (R0 = 1), the expected return value for an ingress/egress filter.
+func newCgroupProgram(t *testing.T) *ebpf.Program {
+	t.Helper()
+	prog, err := ebpf.NewProgram(&ebpf.ProgramSpec{
+		Type: ebpf.CGroupSKB,
+		Instructions: asm.Instructions{
+			asm.LoadImm(asm.R0, 1, asm.DWord),
+			asm.Return(),
+		},
+		License: "MIT",
+	})
+	if err != nil {
+		if errors.Is(err, ebpf.ErrNotSupported) {
+			t.Skip("unsupported")
+		}
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = prog.Close() })
+	return prog
+}
+
+// Minimal cgroup v2 attach/detach pattern, analogous to the raw tracepoint
+// one. AttachCgroup requires a cgroupv2 mount point; skip gracefully where
+// that isn't available. Attaches to a throwaway sub-cgroup rather than the
+// host's root cgroup, so the test can't affect traffic outside itself.
+func TestCgroupPattern(t *testing.T) {
+	cgroup, err := os.MkdirTemp("/sys/fs/cgroup", "ebpf-example-")
+	if err != nil {
+		t.Skip("cgroupv2 mount unavailable:", err)
+	}
+	t.Cleanup(func() { _ = os.Remove(cgroup) })
+
+	prog := newCgroupProgram(t)
+
+	lk, err := link.AttachCgroup(link.CgroupOptions{
+		Path:    cgroup,
+		Attach:  ebpf.AttachCGroupInetIngress,
+		Program: prog,
+	})
+	if errors.Is(err, ebpf.ErrNotSupported) {
+		t.Skip("cgroup attach unsupported")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = lk.Close() })
+
+	// A CGroupSKB program loaded with Attach: AttachCGroupInetIngress
+	// should report that same attach type back through the link, not just
+	// succeed silently.
+	info, err := lk.Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cg := info.Cgroup(); cg != nil {
+		if cg.AttachType != ebpf.AttachCGroupInetIngress {
+			t.Fatalf("lk.Info() reported attach type %v, want %v", cg.AttachType, ebpf.AttachCGroupInetIngress)
+		}
+	} else {
+		t.Log("link backend doesn't expose cgroup info on this kernel; skipping attach-type assertion")
+	}
+
+	// Pin the link to bpffs and reopen it from there, the way a program
+	// meant to outlive the process that attached it would.
+	bpffs, err := os.MkdirTemp("/sys/fs/bpf", "ebpf-example-")
+	if err != nil {
+		t.Skip("bpffs unavailable:", err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(bpffs) })
+
+	pinPath := filepath.Join(bpffs, "cgroup_link")
+	if err := lk.Pin(pinPath); err != nil {
+		if errors.Is(err, ebpf.ErrNotSupported) {
+			t.Skip("pinning unsupported")
+		}
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Remove(pinPath) })
+
+	reopened, err := link.LoadPinnedLink(pinPath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = reopened.Close() })
+}
+
+// Cgroup attach historically used bpf_prog_attach with explicit
+// BPF_F_ALLOW_MULTI/BPF_F_ALLOW_OVERRIDE/BPF_F_REPLACE flags to control
+// whether a second program could coexist with or replace the first.
+// link.AttachCgroup instead goes through bpf_link_create on kernels that
+// support it, where every attachment is its own independent link: multiple
+// programs on the same cgroup+attach-type already coexist without an
+// ALLOW_MULTI flag, and OVERRIDE/REPLACE semantics are simply "close the
+// old link, open a new one". This exercises that multi-attach behaviour
+// directly instead of threading prog_attach flags through link.CgroupOptions,
+// which has no Flags field to set them on.
+func TestCgroupMultiAttachPattern(t *testing.T) {
+	cgroup, err := os.MkdirTemp("/sys/fs/cgroup", "ebpf-example-")
+	if err != nil {
+		t.Skip("cgroupv2 mount unavailable:", err)
+	}
+	t.Cleanup(func() { _ = os.Remove(cgroup) })
+
+	progA := newCgroupProgram(t)
+	progB := newCgroupProgram(t)
+
+	lkA, err := link.AttachCgroup(link.CgroupOptions{
+		Path:    cgroup,
+		Attach:  ebpf.AttachCGroupInetIngress,
+		Program: progA,
+	})
+	if errors.Is(err, ebpf.ErrNotSupported) {
+		t.Skip("cgroup attach unsupported")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = lkA.Close() })
+
+	lkB, err := link.AttachCgroup(link.CgroupOptions{
+		Path:    cgroup,
+		Attach:  ebpf.AttachCGroupInetIngress,
+		Program: progB,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = lkB.Close() })
+}
@@ -0,0 +1,269 @@
+//go:build linux
+
+package examples
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/perf"
+)
+
+// defaultMaxEntriesCeiling and defaultBufferSize are the ceilings TuneForCgroup
+// falls back to when no limit is in effect, matching what rlimit.RemoveMemlock
+// already assumes for an "unlimited" caller: generous enough for the example
+// patterns in this package, not a production sizing policy.
+const (
+	defaultMaxEntriesCeiling = 1 << 16
+	defaultBufferSize        = 4096
+)
+
+// CgroupTuning holds the sizing TuneForCgroup derives from the calling
+// process's cgroup limits.
+type CgroupTuning struct {
+	// MaxEntries is a conservative ceiling for map MaxEntries, derived from
+	// the cgroup's memory.max.
+	MaxEntries uint32
+	// BufferSize is a per-CPU ringbuf/perf buffer size in bytes.
+	// TuneForCgroup only ever lowers it from defaultBufferSize and never
+	// below one 4096-byte page; as defaultBufferSize is itself 4096, it is
+	// always 4096 whatever the cgroup's memory.max and cpu.max.
+	BufferSize int
+}
+
+// TuneForCgroup reads the calling process's cgroup v2 (or hybrid v1/v2)
+// memory and cpu controllers and derives sizing for NewMapWithAutoTune and
+// NewPerfReaderWithAutoTune below. Unlike rlimit.RemoveMemlock, which only
+// deals with the process's own RLIMIT_MEMLOCK, this accounts for
+// cgroup-level memory accounting, which is what actually gates eBPF
+// allocations on kernels new enough to charge them to memcg instead of the
+// memlock rlimit.
+func TuneForCgroup() (CgroupTuning, error) {
+	memMax, err := cgroupMemoryMax()
+	if err != nil {
+		return CgroupTuning{}, err
+	}
+	cpus, err := cgroupCPUQuota()
+	if err != nil {
+		return CgroupTuning{}, err
+	}
+
+	tuning := CgroupTuning{
+		MaxEntries: defaultMaxEntriesCeiling,
+		BufferSize: defaultBufferSize,
+	}
+	if memMax == 0 {
+		// "max" / no controller found: no cgroup-imposed ceiling, keep the
+		// defaults.
+		return tuning, nil
+	}
+
+	// Budget roughly a quarter of the cgroup's memory ceiling for eBPF maps
+	// and buffers combined, split across however many CPUs can run the
+	// program concurrently.
+	budget := memMax / 4
+	perCPU := budget / uint64(cpus)
+
+	if entries := perCPU / 64; entries < uint64(tuning.MaxEntries) {
+		if entries == 0 {
+			entries = 1
+		}
+		tuning.MaxEntries = uint32(entries)
+	}
+	if buf := perCPU / 4; buf < uint64(tuning.BufferSize) {
+		if buf < 4096 {
+			buf = 4096 // perf/ringbuf buffers must be a page multiple
+		}
+		tuning.BufferSize = int(buf)
+	}
+	return tuning, nil
+}
+
+// cgroupMemoryMax returns the calling process's cgroup memory ceiling in
+// bytes, or 0 if none is in effect (no memory controller, or an explicit
+// "max"/unlimited value).
+func cgroupMemoryMax() (uint64, error) {
+	if path, ok := ownCgroupPath(""); ok {
+		// Unified (cgroup v2) hierarchy: one mount, one path. A hybrid host
+		// lists a unified entry too, but has no memory.max under it; it
+		// falls through to the v1 memory mount below.
+		file := "/sys/fs/cgroup" + path + "/memory.max"
+		if _, err := os.Stat(file); err == nil {
+			return readCgroupLimit(file, "max")
+		}
+	}
+	if path, ok := ownCgroupPath("memory"); ok {
+		// Hybrid/legacy (cgroup v1) hierarchy: memory has its own mount.
+		return readCgroupLimit("/sys/fs/cgroup/memory"+path+"/memory.limit_in_bytes", "")
+	}
+	return 0, nil
+}
+
+// cgroupCPUQuota returns an approximation of how many CPUs the calling
+// process's cgroup allows it to use concurrently, rounded up and never
+// below 1. It reads cpu.max (v2) or cpu.cfs_quota_us/cpu.cfs_period_us (v1);
+// an unset or unlimited quota falls back to 1, the conservative choice for
+// sizing a per-CPU buffer.
+func cgroupCPUQuota() (int, error) {
+	// On a hybrid host the unified entry has no cpu.max; fall through to the
+	// v1 cpu mount then.
+	path, ok := ownCgroupPath("")
+	data, err := os.ReadFile("/sys/fs/cgroup" + path + "/cpu.max")
+	if ok && err == nil {
+		fields := strings.Fields(string(data))
+		if len(fields) != 2 || fields[0] == "max" {
+			return 1, nil
+		}
+		quota, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			return 1, nil
+		}
+		period, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil || period == 0 {
+			return 1, nil
+		}
+		if cpus := int(math.Ceil(quota / period)); cpus > 1 {
+			return cpus, nil
+		}
+		return 1, nil
+	}
+	if path, ok := ownCgroupPath("cpu"); ok {
+		quota, qerr := readCgroupLimit("/sys/fs/cgroup/cpu"+path+"/cpu.cfs_quota_us", "")
+		period, perr := readCgroupLimit("/sys/fs/cgroup/cpu"+path+"/cpu.cfs_period_us", "")
+		if qerr == nil && perr == nil && period != 0 {
+			if cpus := int((quota + period - 1) / period); cpus > 1 {
+				return cpus, nil
+			}
+		}
+	}
+	return 1, nil
+}
+
+// readCgroupLimit reads a single-line cgroupfs control file containing
+// either a decimal byte count or unlimitedValue (typically "max"), returning
+// 0 for the unlimited case.
+func readCgroupLimit(path, unlimitedValue string) (uint64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		// Controller not mounted/delegated: treat as no limit rather than
+		// failing the caller outright.
+		return 0, nil
+	}
+	val := strings.TrimSpace(string(data))
+	if unlimitedValue != "" && val == unlimitedValue {
+		return 0, nil
+	}
+	n, err := strconv.ParseUint(val, 10, 64)
+	if err != nil {
+		return 0, nil
+	}
+	return n, nil
+}
+
+// ownCgroupPath looks up the calling process's path within the given
+// controller's hierarchy from /proc/self/cgroup. An empty controller name
+// matches the unified (cgroup v2) entry, identified by an empty controller
+// list. It returns ok=false if /proc/self/cgroup has no matching line.
+func ownCgroupPath(controller string) (string, bool) {
+	f, err := os.Open("/proc/self/cgroup")
+	if err != nil {
+		return "", false
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		fields := strings.SplitN(sc.Text(), ":", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		controllers := fields[1]
+		if controller == "" {
+			if controllers == "" {
+				return fields[2], true
+			}
+			continue
+		}
+		for _, c := range strings.Split(controllers, ",") {
+			if c == controller {
+				return fields[2], true
+			}
+		}
+	}
+	return "", false
+}
+
+// NewMapWithAutoTune creates a map the same way ebpf.NewMapWithOptions does,
+// except spec.MaxEntries is set to tuning.MaxEntries first whenever the
+// caller left it 0 or asked for more than that — CollectionOptions itself
+// has no hook for this, so the tuning is applied to the spec before handing
+// it off.
+//
+// A PerfEventArray needs one slot per possible CPU regardless of how tight
+// the cgroup's memory budget is: NewPerfReaderWithAutoTune below opens one
+// ring per possible CPU against it, so clamping its MaxEntries under that
+// count (as a budget-only floor of 1 would) leaves the reader unable to
+// attach on any host with more than one CPU. A PerfEventArray is floored at
+// the host's possible-CPU count instead, after the budget-derived ceiling
+// is applied; other map types get no such floor.
+func NewMapWithAutoTune(spec *ebpf.MapSpec, tuning CgroupTuning, opts ebpf.MapOptions) (*ebpf.Map, error) {
+	tuned := *spec
+	if tuned.MaxEntries == 0 || tuned.MaxEntries > tuning.MaxEntries {
+		tuned.MaxEntries = tuning.MaxEntries
+	}
+	if spec.Type == ebpf.PerfEventArray {
+		if cpus, err := possibleCPUs(); err == nil && tuned.MaxEntries < uint32(cpus) {
+			tuned.MaxEntries = uint32(cpus)
+		}
+	}
+	return ebpf.NewMapWithOptions(&tuned, opts)
+}
+
+// possibleCPUs returns the number of possible CPUs on the host, parsed from
+// /sys/devices/system/cpu/possible (format: a comma-separated list of
+// decimal values and inclusive ranges, e.g. "0-3" or "0-3,8,10-11") the same
+// way the kernel itself reports the dimension a PerfEventArray's
+// MaxEntries=0 auto-sizing and a per-CPU perf.Reader both need.
+func possibleCPUs() (int, error) {
+	data, err := os.ReadFile("/sys/devices/system/cpu/possible")
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, part := range strings.Split(strings.TrimSpace(string(data)), ",") {
+		if part == "" {
+			continue
+		}
+		lo, hi, ok := strings.Cut(part, "-")
+		first, err := strconv.Atoi(lo)
+		if err != nil {
+			return 0, err
+		}
+		last := first
+		if ok {
+			last, err = strconv.Atoi(hi)
+			if err != nil {
+				return 0, err
+			}
+		}
+		count += last - first + 1
+	}
+	if count == 0 {
+		return 0, fmt.Errorf("examples: no CPUs found in /sys/devices/system/cpu/possible")
+	}
+	return count, nil
+}
+
+// NewPerfReaderWithAutoTune opens a perf.Reader over m sized to
+// tuning.BufferSize per CPU, the consumer for the buffer sizing
+// TuneForCgroup derives alongside MaxEntries above. With TuneForCgroup's
+// current constants that is one 4096-byte page per CPU whatever the
+// cgroup's memory.max; a tight limit shrinks the map, not the perf ring.
+func NewPerfReaderWithAutoTune(m *ebpf.Map, tuning CgroupTuning) (*perf.Reader, error) {
+	return perf.NewReader(m, tuning.BufferSize)
+}
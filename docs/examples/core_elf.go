@@ -0,0 +1,160 @@
+//go:build linux
+
+package examples
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/cilium/ebpf"
+)
+
+// The following hand-assembles a minimal, valid ET_REL BPF ELF object byte
+// for byte, so loadBpfFromELF can drive ebpf.LoadCollectionSpecFromReader's
+// real ELF-parsing path (section/symbol table parsing, license extraction,
+// program-type-from-section-name) instead of only mirroring the generated
+// CollectionSpec shape the way loadBpf in core_min_test.go does. It carries
+// no .BTF/.BTF.ext, so it has nothing for CO-RE relocation to do — that
+// still needs a clang-compiled object and a running kernel's BTF, neither
+// of which exist in this snapshot — but everything ELF-shaped about loading
+// a real object is exercised here.
+
+type elf64Ehdr struct {
+	Ident     [16]byte
+	Type      uint16
+	Machine   uint16
+	Version   uint32
+	Entry     uint64
+	Phoff     uint64
+	Shoff     uint64
+	Flags     uint32
+	Ehsize    uint16
+	Phentsize uint16
+	Phnum     uint16
+	Shentsize uint16
+	Shnum     uint16
+	Shstrndx  uint16
+}
+
+type elf64Shdr struct {
+	Name      uint32
+	Type      uint32
+	Flags     uint64
+	Addr      uint64
+	Off       uint64
+	Size      uint64
+	Link      uint32
+	Info      uint32
+	Addralign uint64
+	Entsize   uint64
+}
+
+type elf64Sym struct {
+	Name  uint32
+	Info  uint8
+	Other uint8
+	Shndx uint16
+	Value uint64
+	Size  uint64
+}
+
+const (
+	etRel       = 1
+	emBPF       = 247
+	shtProgbits = 1
+	shtSymtab   = 2
+	shtStrtab   = 3
+	shfAlloc    = 0x2
+	shfExec     = 0x4
+	stbGlobal   = 1
+	sttFunc     = 2
+)
+
+// buildMinimalBpfELF returns a one-program ELF object: a "socket" section
+// holding `r0 = 0; exit`, a "license" section, and the symbol/string tables
+// needed to name the program "socket" the same way a clang-compiled object
+// with a `SEC("socket")` function would.
+func buildMinimalBpfELF() []byte {
+	license := append([]byte("MIT"), 0)
+	prog := []byte{
+		0xb7, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // r0 = 0
+		0x95, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // exit
+	}
+	symName := []byte("socket\x00")
+	strtab := append([]byte{0}, symName...)
+
+	symtab := make([]byte, 0, 48)
+	symtab = appendSym(symtab, elf64Sym{}) // index 0: null symbol
+	symtab = appendSym(symtab, elf64Sym{
+		Name:  1, // offset of "socket" in strtab
+		Info:  stbGlobal<<4 | sttFunc,
+		Shndx: 2, // index of the "socket" section below
+		Value: 0,
+		Size:  uint64(len(prog)),
+	})
+
+	shstrtab := []byte("\x00license\x00socket\x00.symtab\x00.strtab\x00.shstrtab\x00")
+	nameLicense := uint32(1)
+	nameSocket := nameLicense + uint32(len("license\x00"))
+	nameSymtab := nameSocket + uint32(len("socket\x00"))
+	nameStrtab := nameSymtab + uint32(len(".symtab\x00"))
+	nameShstrtab := nameStrtab + uint32(len(".strtab\x00"))
+
+	const ehdrSize = 64
+
+	licenseOff := uint64(ehdrSize)
+	progOff := licenseOff + uint64(len(license))
+	symtabOff := progOff + uint64(len(prog))
+	strtabOff := symtabOff + uint64(len(symtab))
+	shstrtabOff := strtabOff + uint64(len(strtab))
+	shoff := shstrtabOff + uint64(len(shstrtab))
+
+	ehdr := elf64Ehdr{
+		Type:      etRel,
+		Machine:   emBPF,
+		Version:   1,
+		Shoff:     shoff,
+		Ehsize:    ehdrSize,
+		Shentsize: 64,
+		Shnum:     6,
+		Shstrndx:  5,
+	}
+	ehdr.Ident[0], ehdr.Ident[1], ehdr.Ident[2], ehdr.Ident[3] = 0x7f, 'E', 'L', 'F'
+	ehdr.Ident[4] = 2 // ELFCLASS64
+	ehdr.Ident[5] = 1 // ELFDATA2LSB
+	ehdr.Ident[6] = 1 // EV_CURRENT
+
+	var buf bytes.Buffer
+	_ = binary.Write(&buf, binary.LittleEndian, ehdr)
+	buf.Write(license)
+	buf.Write(prog)
+	buf.Write(symtab)
+	buf.Write(strtab)
+	buf.Write(shstrtab)
+
+	shdrs := []elf64Shdr{
+		{}, // SHT_NULL
+		{Name: nameLicense, Type: shtProgbits, Off: licenseOff, Size: uint64(len(license)), Addralign: 1},
+		{Name: nameSocket, Type: shtProgbits, Flags: shfAlloc | shfExec, Off: progOff, Size: uint64(len(prog)), Addralign: 8},
+		{Name: nameSymtab, Type: shtSymtab, Off: symtabOff, Size: uint64(len(symtab)), Link: 4, Info: 1, Addralign: 8, Entsize: 24},
+		{Name: nameStrtab, Type: shtStrtab, Off: strtabOff, Size: uint64(len(strtab)), Addralign: 1},
+		{Name: nameShstrtab, Type: shtStrtab, Off: shstrtabOff, Size: uint64(len(shstrtab)), Addralign: 1},
+	}
+	for _, sh := range shdrs {
+		_ = binary.Write(&buf, binary.LittleEndian, sh)
+	}
+	return buf.Bytes()
+}
+
+func appendSym(b []byte, sym elf64Sym) []byte {
+	buf := bytes.NewBuffer(b)
+	_ = binary.Write(buf, binary.LittleEndian, sym)
+	return buf.Bytes()
+}
+
+// loadBpfFromELF parses buildMinimalBpfELF's output the way the generated
+// loadBpf would parse a real bpf2go object: via
+// ebpf.LoadCollectionSpecFromReader.
+func loadBpfFromELF() (*ebpf.CollectionSpec, error) {
+	return ebpf.LoadCollectionSpecFromReader(bytes.NewReader(buildMinimalBpfELF()))
+}
@@ -0,0 +1,88 @@
+//go:build linux
+
+package examples
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/cilium/ebpf/perf"
+)
+
+// FlightRecorder drains a perf.Reader into a fixed-size window of the most
+// recent samples in userspace: the same "keep the latest N, drop the rest"
+// behaviour a kernel-side overwritable perf ring would give, but built
+// entirely on top of a plain perf.Reader rather than a write_backward ring
+// mode, which cilium/ebpf's perf package has no actual support for (there
+// is no Overwritable field on ReaderOptions, and no public hook to flip a
+// live perf_event fd between normal and overwrite mode). Pause/Resume here
+// are a userspace-only gate on whether drained samples get folded into the
+// window; they don't touch the underlying perf_event fd at all, so pausing
+// doesn't stop the kernel from writing — it only stops this recorder from
+// keeping what it reads.
+type FlightRecorder struct {
+	rd *perf.Reader
+
+	mu     sync.Mutex
+	window []perf.Record
+	cap    int
+
+	paused atomic.Bool
+	done   chan struct{}
+}
+
+// NewFlightRecorder starts draining rd in the background, keeping the most
+// recent window samples. The caller remains responsible for closing rd;
+// closing it also stops the recorder's goroutine.
+func NewFlightRecorder(rd *perf.Reader, window int) *FlightRecorder {
+	f := &FlightRecorder{
+		rd:   rd,
+		cap:  window,
+		done: make(chan struct{}),
+	}
+	go f.run()
+	return f
+}
+
+func (f *FlightRecorder) run() {
+	defer close(f.done)
+	for {
+		rec, err := f.rd.Read()
+		if err != nil {
+			return
+		}
+		if f.paused.Load() {
+			continue
+		}
+		f.mu.Lock()
+		f.window = append(f.window, rec)
+		if len(f.window) > f.cap {
+			f.window = f.window[len(f.window)-f.cap:]
+		}
+		f.mu.Unlock()
+	}
+}
+
+// Pause stops folding newly read samples into the window. Samples read
+// while paused are discarded rather than queued.
+func (f *FlightRecorder) Pause() { f.paused.Store(true) }
+
+// Resume undoes Pause.
+func (f *FlightRecorder) Resume() { f.paused.Store(false) }
+
+// Snapshot returns a copy of the samples currently held in the window,
+// oldest first, the way a triggered dump of a flight recorder would.
+func (f *FlightRecorder) Snapshot() []perf.Record {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := make([]perf.Record, len(f.window))
+	copy(out, f.window)
+	return out
+}
+
+// Wait blocks until the background drain goroutine has exited, which
+// happens once the underlying reader is closed. Tests use this to avoid
+// racing a Snapshot against the last few in-flight samples.
+func (f *FlightRecorder) Wait() {
+	<-f.done
+}
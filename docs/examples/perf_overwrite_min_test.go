@@ -0,0 +1,106 @@
+//go:build linux
+
+package examples
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/perf"
+)
+
+// waitForSnapshotLen polls fr.Snapshot() until it reaches n records or
+// timeout elapses, returning the last snapshot observed either way.
+func waitForSnapshotLen(fr *FlightRecorder, n int, timeout time.Duration) []perf.Record {
+	deadline := time.Now().Add(timeout)
+	for {
+		snap := fr.Snapshot()
+		if len(snap) >= n || time.Now().After(deadline) {
+			return snap
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// Snapshot-mode perf reader pattern: FlightRecorder builds a queryable
+// "last N samples" window on top of a plain perf.Reader (see
+// perf_flight_recorder.go for why this is emulated in userspace rather than
+// via a kernel-side overwritable ring), exercised end-to-end against
+// perf_prog from core_min_test.go: pause and confirm the dump stops
+// growing, resume and confirm it grows again, then trigger it past the
+// window's size and confirm the dump is capped.
+func TestPerfOverwritePattern(t *testing.T) {
+	var objs bpfObjects
+	if err := loadBpfObjects(&objs, nil); err != nil {
+		if errors.Is(err, ebpf.ErrNotSupported) {
+			t.Skip("unsupported")
+		}
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = objs.Close() })
+
+	rd, err := perf.NewReader(objs.PerfMap, 4096)
+	if errors.Is(err, ebpf.ErrNotSupported) {
+		t.Skip("perf reader unsupported")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = rd.Close() })
+
+	const window = 3
+	fr := NewFlightRecorder(rd, window)
+
+	trigger := func(n int) {
+		in := make([]byte, 14)
+		for i := 0; i < n; i++ {
+			if _, _, runErr := objs.PerfProg.Test(in); runErr != nil {
+				if errors.Is(runErr, ebpf.ErrNotSupported) {
+					t.Skip("prog test run not supported")
+				}
+				t.Fatal(runErr)
+			}
+		}
+	}
+
+	// Pause and Resume are checked while the window still has room: once it
+	// is full its length stays at `window` whether or not Pause works.
+	trigger(1)
+	first := waitForSnapshotLen(fr, 1, 2*time.Second)
+	if len(first) != 1 {
+		t.Fatalf("expected 1 sample before pausing, got %d", len(first))
+	}
+
+	fr.Pause()
+	time.Sleep(20 * time.Millisecond) // let any in-flight Read settle
+	trigger(window)
+	time.Sleep(50 * time.Millisecond)
+	if paused := fr.Snapshot(); len(paused) != len(first) {
+		t.Fatalf("snapshot grew from %d to %d samples while paused", len(first), len(paused))
+	}
+
+	fr.Resume()
+	trigger(1)
+	resumed := waitForSnapshotLen(fr, len(first)+1, 2*time.Second)
+	if len(resumed) <= len(first) {
+		t.Fatalf("expected snapshot to grow past %d samples after Resume, got %d", len(first), len(resumed))
+	}
+
+	// Write more samples than the window holds; the dump should be capped
+	// at `window`, the flight-recorder behaviour under test.
+	trigger(window + 2)
+	snap := waitForSnapshotLen(fr, window, 2*time.Second)
+	if len(snap) != window {
+		t.Fatalf("expected snapshot capped at %d samples, got %d", window, len(snap))
+	}
+
+	// Close rd and wait for the drain goroutine to observe the resulting
+	// Read error and exit, so nothing races a post-test Snapshot against a
+	// sample still in flight.
+	if err := rd.Close(); err != nil {
+		t.Fatal(err)
+	}
+	fr.Wait()
+}
@@ -0,0 +1,160 @@
+//go:build linux
+
+package examples
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/ringbuf"
+)
+
+// BatchReader adds a batch/peek surface on top of a single-record
+// ringbuf.Reader.
+//
+// cilium/ebpf's ringbuf.Reader already copies each sample out of the
+// mmap'd ring before Read returns it, skips busy/discarded records, and
+// handles wrap-around internally — the ring mmap itself is unexported, so
+// none of that is reachable from outside the ringbuf package in this
+// snapshot. BatchReader works within that: it amortizes the per-call
+// overhead of draining many records at once and lets a caller inspect a
+// record via Peek before deciding whether to Release it. ReadBatch takes
+// caller-supplied buffers (dst), the same shape a zero-copy API would use
+// even though, per the above, this still copies each RawSample into its
+// dst slot rather than handing back a view into the ring itself.
+type BatchReader struct {
+	rd      *ringbuf.Reader
+	pending []ringbuf.Record
+}
+
+// NewBatchReader wraps rd. The caller remains responsible for closing rd.
+func NewBatchReader(rd *ringbuf.Reader) *BatchReader {
+	return &BatchReader{rd: rd}
+}
+
+// Peek returns the next record without consuming it: repeated Peek calls
+// return the same record until Release is called.
+func (b *BatchReader) Peek() (ringbuf.Record, error) {
+	if len(b.pending) == 0 {
+		rec, err := b.rd.Read()
+		if err != nil {
+			return ringbuf.Record{}, err
+		}
+		b.pending = append(b.pending, rec)
+	}
+	return b.pending[0], nil
+}
+
+// Release drops the record returned by the last Peek, if any, so the next
+// Peek or ReadBatch call advances past it.
+func (b *BatchReader) Release() {
+	if len(b.pending) > 0 {
+		b.pending = b.pending[1:]
+	}
+}
+
+// ReadBatch fills as many of dst's slots as it can with the next records'
+// RawSample bytes, consuming any pending Peek'd record first, and returns
+// how many it filled. It honours maxWait the same way a bare
+// rd.SetDeadline/Read loop would: once the deadline passes mid-batch,
+// however many slots were already filled are returned instead of blocking
+// for the rest. A dst slot shorter than the record it receives gets a
+// truncated copy, same as Go's built-in copy.
+func (b *BatchReader) ReadBatch(dst [][]byte, maxWait time.Duration) (int, error) {
+	if err := b.rd.SetDeadline(time.Now().Add(maxWait)); err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for n < len(dst) && len(b.pending) > 0 {
+		copy(dst[n], b.pending[0].RawSample)
+		b.pending = b.pending[1:]
+		n++
+	}
+	for n < len(dst) {
+		rec, err := b.rd.Read()
+		if err != nil {
+			if errors.Is(err, ringbuf.ErrClosed) || errors.Is(err, os.ErrDeadlineExceeded) {
+				break
+			}
+			return n, err
+		}
+		copy(dst[n], rec.RawSample)
+		n++
+	}
+	return n, nil
+}
+
+// Batch-read pattern on top of BatchReader, exercised end-to-end against
+// ringbuf_prog from core_min_test.go: ringbuf_prog's sample carries the
+// triggering skb's length (see core_min_test.go), so triggering it with a
+// distinct input length each time gives distinguishable samples and lets
+// this test assert their sequence, not just their count. Peek the first
+// sample without consuming it, then drain the rest as a batch and confirm
+// they arrive in the same order they were triggered in.
+func TestRingbufBatchPattern(t *testing.T) {
+	var objs bpfObjects
+	if err := loadBpfObjects(&objs, nil); err != nil {
+		if errors.Is(err, ebpf.ErrNotSupported) {
+			t.Skip("unsupported")
+		}
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = objs.Close() })
+
+	rd, err := objs.RingbufReader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = rd.Close() })
+
+	br := NewBatchReader(rd)
+
+	sizes := []int{14, 22, 30, 38}
+	for _, n := range sizes {
+		if _, _, runErr := objs.RingbufProg.Test(make([]byte, n)); runErr != nil {
+			if errors.Is(runErr, ebpf.ErrNotSupported) {
+				t.Skip("prog test run not supported")
+			}
+			t.Fatal(runErr)
+		}
+	}
+
+	peeked, err := br.Peek()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := binary.LittleEndian.Uint64(peeked.RawSample); got != uint64(sizes[0]) {
+		t.Fatalf("expected first sample to carry skb length %d, got %d", sizes[0], got)
+	}
+	// Peeking again without releasing must return the same record, not
+	// advance past it.
+	if again, err := br.Peek(); err != nil || binary.LittleEndian.Uint64(again.RawSample) != uint64(sizes[0]) {
+		t.Fatalf("Peek without Release should be idempotent, got record=%v err=%v", again, err)
+	}
+	br.Release()
+
+	dst := make([][]byte, len(sizes)-1)
+	for i := range dst {
+		dst[i] = make([]byte, 8)
+	}
+	n, err := br.ReadBatch(dst, 2*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(dst) {
+		t.Fatalf("expected %d records, got %d", len(dst), n)
+	}
+	// bpf_ringbuf_output preserves submission order for a single producer,
+	// so the remaining lengths should come back in the same order they
+	// were triggered in.
+	for i, want := range sizes[1:] {
+		if got := binary.LittleEndian.Uint64(dst[i]); got != uint64(want) {
+			t.Fatalf("sample %d: expected skb length %d, got %d", i, want, got)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+//go:build linux
+
+package examples
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/rlimit"
+)
+
+// Minimal memory-tuning pattern: lift RLIMIT_MEMLOCK (a no-op on kernels
+// that charge eBPF memory to the cgroup instead) before the first
+// NewMap/NewProgram call, then size the map itself to whatever the calling
+// cgroup's memory.max/cpu.max actually allow, rather than leaving callers to
+// hit EPERM or ENOMEM on a cgroup-constrained host.
+func TestRlimitPattern(t *testing.T) {
+	if err := rlimit.RemoveMemlock(); err != nil {
+		t.Fatal(err)
+	}
+
+	tuning, err := TuneForCgroup()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := NewMapWithAutoTune(&ebpf.MapSpec{Type: ebpf.Array, KeySize: 4, ValueSize: 4, MaxEntries: 1}, tuning, ebpf.MapOptions{})
+	if err != nil {
+		if errors.Is(err, ebpf.ErrNotSupported) {
+			t.Skip("unsupported")
+		}
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = m.Close() })
+
+	prog, err := ebpf.NewProgram(&ebpf.ProgramSpec{
+		Type: ebpf.SocketFilter,
+		Instructions: asm.Instructions{
+			asm.LoadImm(asm.R0, 0, asm.DWord),
+			asm.Return(),
+		},
+		License: "MIT",
+	})
+	if err != nil {
+		if errors.Is(err, ebpf.ErrNotSupported) {
+			t.Skip("unsupported")
+		}
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = prog.Close() })
+}
+
+// TuneForCgroup itself is pure userspace file parsing, so it's worth
+// checking in isolation without touching the kernel's bpf() syscall at all.
+func TestTuneForCgroup(t *testing.T) {
+	tuning, err := TuneForCgroup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tuning.MaxEntries == 0 {
+		t.Fatal("MaxEntries must never be tuned down to 0")
+	}
+	if tuning.BufferSize < 4096 {
+		t.Fatalf("BufferSize must be at least one page, got %d", tuning.BufferSize)
+	}
+}
+
+// A tight budget must not clamp a PerfEventArray below the host's
+// possible-CPU count: NewPerfReaderWithAutoTune opens one ring per possible
+// CPU against it, so a narrower map would fail to attach on any host with
+// more than one CPU.
+func TestMapWithAutoTunePerfEventArrayFloor(t *testing.T) {
+	if err := rlimit.RemoveMemlock(); err != nil {
+		t.Fatal(err)
+	}
+
+	cpus, err := possibleCPUs()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tight := CgroupTuning{MaxEntries: 1, BufferSize: 4096}
+	m, err := NewMapWithAutoTune(&ebpf.MapSpec{Type: ebpf.PerfEventArray, KeySize: 4, ValueSize: 4}, tight, ebpf.MapOptions{})
+	if err != nil {
+		if errors.Is(err, ebpf.ErrNotSupported) {
+			t.Skip("unsupported")
+		}
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = m.Close() })
+
+	if got := m.MaxEntries(); got < uint32(cpus) {
+		t.Fatalf("PerfEventArray clamped to %d entries under a tight budget, want at least %d (possible CPUs)", got, cpus)
+	}
+}
+
+// NewMapWithAutoTune above consumes tuning.MaxEntries; this exercises the
+// other half of CgroupTuning, tuning.BufferSize, via
+// NewPerfReaderWithAutoTune.
+func TestPerfReaderWithAutoTune(t *testing.T) {
+	if err := rlimit.RemoveMemlock(); err != nil {
+		t.Fatal(err)
+	}
+
+	tuning, err := TuneForCgroup()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := NewMapWithAutoTune(&ebpf.MapSpec{Type: ebpf.PerfEventArray, KeySize: 4, ValueSize: 4}, tuning, ebpf.MapOptions{})
+	if err != nil {
+		if errors.Is(err, ebpf.ErrNotSupported) {
+			t.Skip("unsupported")
+		}
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = m.Close() })
+
+	rd, err := NewPerfReaderWithAutoTune(m, tuning)
+	if err != nil {
+		if errors.Is(err, ebpf.ErrNotSupported) {
+			t.Skip("unsupported")
+		}
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = rd.Close() })
+}
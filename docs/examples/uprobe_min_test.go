@@ -0,0 +1,125 @@
+//go:build linux
+
+package examples
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/link"
+)
+
+const uprobeTargetBinary = "/bin/bash"
+const uprobeTargetSymbol = "readline"
+
+func newKprobeTypeProgram(t *testing.T) *ebpf.Program {
+	t.Helper()
+	prog, err := ebpf.NewProgram(&ebpf.ProgramSpec{
+		Type: ebpf.Kprobe,
+		Instructions: asm.Instructions{
+			asm.LoadImm(asm.R0, 0, asm.DWord),
+			asm.Return(),
+		},
+		License: "MIT",
+	})
+	if err != nil {
+		if errors.Is(err, ebpf.ErrNotSupported) {
+			t.Skip("unsupported")
+		}
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = prog.Close() })
+	return prog
+}
+
+func openUprobeTarget(t *testing.T) *link.Executable {
+	t.Helper()
+	if _, err := os.Stat(uprobeTargetBinary); err != nil {
+		t.Skip("uprobe target binary unavailable:", err)
+	}
+	ex, err := link.OpenExecutable(uprobeTargetBinary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ex
+}
+
+// Minimal uprobe attach/detach pattern, mirroring the raw tracepoint one:
+// open the target executable once, then attach a uprobe by symbol name. The
+// target is /bin/bash's readline, a real exported symbol almost every host
+// carries, rather than a symbol in the test binary itself (whose layout and
+// inlining are controlled by the test compiler, not by what's being
+// demonstrated here). On unsupported kernels or a missing symbol it should
+// be skipped gracefully.
+func TestUprobePattern(t *testing.T) {
+	ex := openUprobeTarget(t)
+	prog := newKprobeTypeProgram(t)
+
+	lk, err := AttachUprobeSymbol(ex, uprobeTargetSymbol, prog, nil)
+	if errors.Is(err, ebpf.ErrNotSupported) || errors.Is(err, link.ErrNoSymbol) {
+		t.Skip("uprobe unsupported or symbol not found:", err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = lk.Close() })
+}
+
+// Uretprobes fire on function return instead of entry, otherwise attaching
+// the same way as a uprobe.
+func TestUretprobePattern(t *testing.T) {
+	ex := openUprobeTarget(t)
+	prog := newKprobeTypeProgram(t)
+
+	lk, err := AttachUretprobeSymbol(ex, uprobeTargetSymbol, prog, nil)
+	if errors.Is(err, ebpf.ErrNotSupported) || errors.Is(err, link.ErrNoSymbol) {
+		t.Skip("uretprobe unsupported or symbol not found:", err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = lk.Close() })
+}
+
+// USDT probes attach by provider/name instead of a raw symbol: their
+// address and optional semaphore come from parsing the binary's
+// .note.stapsdt notes (see usdt.go), not from the symbol table. glibc
+// itself carries USDT notes (provider "libc"), so it doubles as a
+// dependency-free target here; skip where that's not the case (e.g. a libc
+// built without USDT support, or a non-glibc libc).
+func TestUsdtPattern(t *testing.T) {
+	const libc = "/lib/x86_64-linux-gnu/libc.so.6"
+	const provider, name = "libc", "setjmp"
+
+	if _, err := os.Stat(libc); err != nil {
+		t.Skip("USDT target library unavailable:", err)
+	}
+
+	// Not every libc build ships USDT notes (and some place them under a
+	// different provider/name than expected here), so a missing probe is
+	// an expected outcome on some hosts; check for that up front rather
+	// than folding it into AttachUsdt's error below, where it would mask a
+	// real bug in fileOffset/processLoadBias behind the same t.Skip.
+	if _, err := FindUsdtProbe(libc, provider, name); err != nil {
+		t.Skip("USDT probe not present in libc:", err)
+	}
+
+	ex, err := link.OpenExecutable(libc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prog := newKprobeTypeProgram(t)
+
+	lk, err := AttachUsdt(ex, libc, os.Getpid(), provider, name, prog)
+	if errors.Is(err, ebpf.ErrNotSupported) || errors.Is(err, link.ErrNoSymbol) {
+		t.Skip("USDT probe unsupported on this kernel:", err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = lk.Close() })
+}
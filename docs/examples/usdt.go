@@ -0,0 +1,318 @@
+//go:build linux
+
+package examples
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/link"
+)
+
+// noteTypeStapsdt is NT_STAPSDT, the SystemTap USDT note type emitted into
+// .note.stapsdt by DTRACE_PROBE-instrumented binaries (glibc, libpq,
+// node, ...). See SystemTap's sys/sdt.h for the note layout this decodes.
+const noteTypeStapsdt = 3
+
+// UsdtProbe describes a single USDT probe point decoded from an ELF
+// binary's .note.stapsdt section.
+type UsdtProbe struct {
+	Provider string
+	Name     string
+
+	// Location, Base and Semaphore are link-time virtual addresses as
+	// recorded in the note; call fileOffset to translate Location into a
+	// file offset suitable for uprobe attachment.
+	Location  uint64
+	Base      uint64
+	Semaphore uint64
+
+	// Arguments is the raw SystemTap argument format string, e.g.
+	// "-4@%eax 8@%rdi"; parsing it is out of scope here.
+	Arguments string
+}
+
+// ParseUsdtNotes walks the .note.stapsdt section of the ELF file at path
+// and decodes every USDT probe it advertises.
+func ParseUsdtNotes(path string) ([]UsdtProbe, error) {
+	f, err := elf.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	sec := f.Section(".note.stapsdt")
+	if sec == nil {
+		return nil, fmt.Errorf("examples: %s has no .note.stapsdt section", path)
+	}
+	data, err := sec.Data()
+	if err != nil {
+		return nil, err
+	}
+
+	var probes []UsdtProbe
+	for len(data) >= 12 {
+		namesz := f.ByteOrder.Uint32(data[0:4])
+		descsz := f.ByteOrder.Uint32(data[4:8])
+		typ := f.ByteOrder.Uint32(data[8:12])
+		off := 12
+
+		nameEnd := off + int(namesz)
+		if nameEnd > len(data) {
+			break
+		}
+		name := cString(data[off:nameEnd])
+		off += alignUp(int(namesz), 4)
+
+		descEnd := off + int(descsz)
+		if descEnd > len(data) {
+			break
+		}
+		desc := data[off:descEnd]
+		off += alignUp(int(descsz), 4)
+
+		if off > len(data) {
+			break
+		}
+		data = data[off:]
+
+		if typ != noteTypeStapsdt || name != "stapsdt" {
+			continue
+		}
+		probe, err := parseStapsdtDescriptor(f.ByteOrder, desc)
+		if err != nil {
+			continue
+		}
+		probes = append(probes, probe)
+	}
+	return probes, nil
+}
+
+// parseStapsdtDescriptor decodes one NT_STAPSDT note descriptor: three
+// address-sized fields (location, base, semaphore) followed by three
+// NUL-terminated strings (provider, name, argument format).
+func parseStapsdtDescriptor(order binary.ByteOrder, desc []byte) (UsdtProbe, error) {
+	const addrSize = 8
+	if len(desc) < 3*addrSize {
+		return UsdtProbe{}, fmt.Errorf("examples: truncated stapsdt descriptor")
+	}
+	probe := UsdtProbe{
+		Location:  order.Uint64(desc[0*addrSize:]),
+		Base:      order.Uint64(desc[1*addrSize:]),
+		Semaphore: order.Uint64(desc[2*addrSize:]),
+	}
+	rest := desc[3*addrSize:]
+	parts := bytes.SplitN(rest, []byte{0}, 3)
+	if len(parts) > 0 {
+		probe.Provider = string(parts[0])
+	}
+	if len(parts) > 1 {
+		probe.Name = string(parts[1])
+	}
+	if len(parts) > 2 {
+		probe.Arguments = string(bytes.TrimRight(parts[2], "\x00"))
+	}
+	return probe, nil
+}
+
+func cString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return string(b)
+}
+
+func alignUp(n, align int) int {
+	return (n + align - 1) &^ (align - 1)
+}
+
+// fileOffset translates a probe's link-time virtual address into a file
+// offset via the PT_LOAD segment that contains it, the form the kernel's
+// uprobe attachment works in. No runtime (PIE/ASLR) load bias is involved:
+// that only matters for live addresses, see processLoadBias.
+func fileOffset(f *elf.File, vaddr uint64) (uint64, error) {
+	for _, prog := range f.Progs {
+		if prog.Type != elf.PT_LOAD {
+			continue
+		}
+		if vaddr >= prog.Vaddr && vaddr < prog.Vaddr+prog.Filesz {
+			return vaddr - prog.Vaddr + prog.Off, nil
+		}
+	}
+	return 0, fmt.Errorf("examples: vaddr %#x is not mapped by any PT_LOAD segment", vaddr)
+}
+
+// stripSymbolVersion removes a glibc-style "@GLIBC_2.34" (or "@@GLIBC_2.34")
+// version suffix from a symbol name, the way a semaphore symbol copied out
+// of readelf/nm output would carry one.
+func stripSymbolVersion(symbol string) string {
+	if i := strings.IndexByte(symbol, '@'); i >= 0 {
+		return symbol[:i]
+	}
+	return symbol
+}
+
+// AttachUprobeSymbol attaches prog to the function symbol in ex, stripping
+// any glibc-style version suffix from symbol first: link.Executable.Uprobe
+// resolves against the plain name in the ELF symbol table, so a symbol
+// copied out of readelf/nm output (which prints the versioned form) would
+// otherwise fail to resolve.
+func AttachUprobeSymbol(ex *link.Executable, symbol string, prog *ebpf.Program, opts *link.UprobeOptions) (link.Link, error) {
+	return ex.Uprobe(stripSymbolVersion(symbol), prog, opts)
+}
+
+// AttachUretprobeSymbol is AttachUprobeSymbol for a uretprobe.
+func AttachUretprobeSymbol(ex *link.Executable, symbol string, prog *ebpf.Program, opts *link.UprobeOptions) (link.Link, error) {
+	return ex.Uretprobe(stripSymbolVersion(symbol), prog, opts)
+}
+
+// FindUsdtProbe returns the probe matching provider/name, or an error if the
+// binary at path carries no such probe.
+func FindUsdtProbe(path, provider, name string) (UsdtProbe, error) {
+	probes, err := ParseUsdtNotes(path)
+	if err != nil {
+		return UsdtProbe{}, err
+	}
+	for _, p := range probes {
+		if p.Provider == provider && p.Name == name {
+			return p, nil
+		}
+	}
+	return UsdtProbe{}, fmt.Errorf("examples: no USDT probe %s:%s in %s", provider, name, path)
+}
+
+// AttachUsdt attaches prog to the USDT probe provider:name in the binary ex
+// was opened from (elfPath must be the same file ex wraps). pid identifies
+// the process the probe will actually fire in, needed only for the
+// activateSemaphore fallback below. Unlike a plain symbol uprobe, a USDT
+// probe's address comes from parsed .note.stapsdt data rather than the
+// symbol table, and its semaphore (if any) is wired through
+// link.UprobeOptions.RefCtrOffset so the kernel increments/decrements it
+// itself around the probe firing — the uprobe/uretprobe attach path this
+// goes through already falls back from BPF_LINK_CREATE to
+// perf_event_open+PERF_EVENT_IOC_SET_BPF on older kernels internally, same
+// as the plain symbol case in TestUprobePattern. On a kernel old enough that
+// RefCtrOffset itself isn't supported (pre-4.20), it retries without
+// RefCtrOffset and instead activates the semaphore by hand via
+// activateSemaphore, the way the probe's own provider would if no
+// ref_ctr_offset-aware consumer had ever attached to it.
+func AttachUsdt(ex *link.Executable, elfPath string, pid int, provider, name string, prog *ebpf.Program) (link.Link, error) {
+	probe, err := FindUsdtProbe(elfPath, provider, name)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := elf.Open(elfPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	offset, err := fileOffset(f, probe.Location)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := &link.UprobeOptions{Address: offset}
+	if probe.Semaphore != 0 {
+		semaOffset, err := fileOffset(f, probe.Semaphore)
+		if err != nil {
+			return nil, err
+		}
+		opts.RefCtrOffset = semaOffset
+	}
+	// The symbol argument is ignored whenever Address is set, which is
+	// always true for a USDT probe resolved from notes rather than a
+	// function symbol.
+	lk, err := ex.Uprobe("", prog, opts)
+	if probe.Semaphore != 0 && errors.Is(err, ebpf.ErrNotSupported) {
+		bias, biasErr := processLoadBias(pid, elfPath)
+		if biasErr != nil {
+			return nil, biasErr
+		}
+		if semErr := activateSemaphore(pid, bias+probe.Semaphore, 1); semErr != nil {
+			return nil, semErr
+		}
+		opts.RefCtrOffset = 0
+		lk, err = ex.Uprobe("", prog, opts)
+	}
+	return lk, err
+}
+
+// processLoadBias returns the runtime load bias pid has mapped elfPath at:
+// the amount to add to a link-time virtual address (as recorded in
+// .note.stapsdt, or any other link-time address) to get the corresponding
+// live address in that process. A non-PIE executable (ET_EXEC) has no bias
+// — link-time and runtime addresses already match. A PIE/shared object
+// (ET_DYN) is found by locating its first mapping (file offset 0) in
+// /proc/<pid>/maps; that mapping's start address is the bias, since the
+// first PT_LOAD segment of a well-formed shared object starts at vaddr 0.
+func processLoadBias(pid int, elfPath string) (uint64, error) {
+	f, err := elf.Open(elfPath)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	if f.Type != elf.ET_DYN {
+		return 0, nil
+	}
+
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/maps", pid))
+	if err != nil {
+		return 0, err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 6 || fields[5] != elfPath {
+			continue
+		}
+		start, _, ok := strings.Cut(fields[0], "-")
+		if !ok {
+			continue
+		}
+		startAddr, err := strconv.ParseUint(start, 16, 64)
+		if err != nil {
+			continue
+		}
+		fileOff, err := strconv.ParseUint(fields[2], 16, 64)
+		if err != nil {
+			continue
+		}
+		if fileOff == 0 {
+			return startAddr, nil
+		}
+	}
+	return 0, fmt.Errorf("examples: %s not mapped in /proc/%d/maps", elfPath, pid)
+}
+
+// activateSemaphore is the manual fallback for kernels older than 4.20,
+// which lack ref_ctr_offset support and so never increment a USDT
+// semaphore on attach: the enabling process has to poke the counter in the
+// target's address space itself via /proc/<pid>/mem. Kernels new enough for
+// RefCtrOffset (the path AttachUsdt takes above) don't need this.
+func activateSemaphore(pid int, addr uint64, delta int16) error {
+	mem, err := os.OpenFile(fmt.Sprintf("/proc/%d/mem", pid), os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer mem.Close()
+
+	var buf [2]byte
+	if _, err := mem.ReadAt(buf[:], int64(addr)); err != nil {
+		return fmt.Errorf("examples: reading semaphore at %#x: %w", addr, err)
+	}
+	count := int16(binary.LittleEndian.Uint16(buf[:]))
+	count += delta
+	binary.LittleEndian.PutUint16(buf[:], uint16(count))
+	if _, err := mem.WriteAt(buf[:], int64(addr)); err != nil {
+		return fmt.Errorf("examples: writing semaphore at %#x: %w", addr, err)
+	}
+	return nil
+}